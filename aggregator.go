@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"netmon/geo"
+)
+
+// datausage holds the running RX/TX byte and packet totals for a single
+// tracked IP.
+type datausage struct {
+	rxBytes   uint64
+	txBytes   uint64
+	rxPackets uint64
+	txPackets uint64
+}
+
+// aggregator accumulates per-IP counters in memory so processPacket can
+// run on the hot path without touching disk. A flusher periodically
+// copies it into a report, and the metrics HTTP server (see metrics.go)
+// reads it on every scrape, hence the RWMutex.
+type aggregator struct {
+	mu    sync.RWMutex
+	usage map[string]*datausage
+}
+
+func newAggregator() *aggregator {
+	return &aggregator{usage: make(map[string]*datausage)}
+}
+
+// add records one packet of packetSize bytes for ip in the given direction.
+func (a *aggregator) add(ip, direction string, packetSize uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	u, ok := a.usage[ip]
+	if !ok {
+		u = &datausage{}
+		a.usage[ip] = u
+	}
+
+	switch direction {
+	case "Download":
+		u.rxBytes += packetSize
+		u.rxPackets++
+	case "Upload":
+		u.txBytes += packetSize
+		u.txPackets++
+	}
+}
+
+// snapshotRow is one flushed, point-in-time view of an IP's counters.
+// Country/City/ASN/ASOrg are left zero-valued unless geo enrichment is
+// configured.
+type snapshotRow struct {
+	IP      string
+	RxBytes uint64
+	TxBytes uint64
+	Total   uint64
+	Country string
+	City    string
+	ASN     uint
+	ASOrg   string
+}
+
+// snapshot copies the current counters into a sorted slice of rows,
+// ready to be written out by a flusher. The counters are not reset, so
+// each snapshot holds running totals.
+func (a *aggregator) snapshot(sortField, sortDir string) []snapshotRow {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	rows := make([]snapshotRow, 0, len(a.usage))
+	for ip, u := range a.usage {
+		rows = append(rows, snapshotRow{
+			IP:      ip,
+			RxBytes: u.rxBytes,
+			TxBytes: u.txBytes,
+			Total:   u.rxBytes + u.txBytes,
+		})
+	}
+
+	sortSnapshotRows(rows, sortField, sortDir)
+	return rows
+}
+
+// metricsRow is the per-IP view the metrics HTTP server scrapes.
+type metricsRow struct {
+	IP        string
+	RxBytes   uint64
+	TxBytes   uint64
+	RxPackets uint64
+	TxPackets uint64
+}
+
+// metricsSnapshot returns the current counters for every tracked IP,
+// unsorted, for the /metrics and /stats.json handlers.
+func (a *aggregator) metricsSnapshot() []metricsRow {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	rows := make([]metricsRow, 0, len(a.usage))
+	for ip, u := range a.usage {
+		rows = append(rows, metricsRow{
+			IP:        ip,
+			RxBytes:   u.rxBytes,
+			TxBytes:   u.txBytes,
+			RxPackets: u.rxPackets,
+			TxPackets: u.txPackets,
+		})
+	}
+	return rows
+}
+
+func sortSnapshotRows(rows []snapshotRow, field, dir string) {
+	less := func(i, j int) bool {
+		switch field {
+		case "ip":
+			return rows[i].IP < rows[j].IP
+		case "rx":
+			return rows[i].RxBytes < rows[j].RxBytes
+		case "tx":
+			return rows[i].TxBytes < rows[j].TxBytes
+		default: // total
+			return rows[i].Total < rows[j].Total
+		}
+	}
+	if dir == "desc" {
+		inner := less
+		less = func(i, j int) bool { return inner(j, i) }
+	}
+	sort.Slice(rows, less)
+}
+
+// flusher periodically aggregates the in-memory counters into a report
+// (CSV or SQLite, depending on reportWriter) and prints a sorted,
+// human-readable text snapshot.
+type flusher struct {
+	agg       *aggregator
+	writer    reportWriter
+	geo       *geo.Resolver
+	interval  time.Duration
+	unit      string
+	sortField string
+	sortDir   string
+}
+
+func newFlusher(agg *aggregator, writer reportWriter, geoResolver *geo.Resolver, cfg *config) *flusher {
+	return &flusher{
+		agg:       agg,
+		writer:    writer,
+		geo:       geoResolver,
+		interval:  cfg.FlushInterval,
+		unit:      cfg.Unit,
+		sortField: cfg.SortField,
+		sortDir:   cfg.SortDir,
+	}
+}
+
+// run blocks, flushing every interval until stop is closed.
+func (f *flusher) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			f.flushOnce(now)
+		case <-stop:
+			f.flushOnce(time.Now())
+			return
+		}
+	}
+}
+
+func (f *flusher) flushOnce(now time.Time) {
+	rows := f.agg.snapshot(f.sortField, f.sortDir)
+	f.enrichWithGeo(rows)
+
+	if err := f.writer.writeSnapshot(now, rows); err != nil {
+		log.Println("error writing report:", err)
+	}
+
+	printTextSnapshot(now, rows, f.unit)
+}
+
+// enrichWithGeo fills in country/city/ASN for each row when geo
+// enrichment is configured; it's a no-op otherwise.
+func (f *flusher) enrichWithGeo(rows []snapshotRow) {
+	if f.geo == nil {
+		return
+	}
+	for i := range rows {
+		ip := net.ParseIP(rows[i].IP)
+		if ip == nil {
+			continue
+		}
+		info := f.geo.Lookup(ip)
+		rows[i].Country = info.Country
+		rows[i].City = info.City
+		rows[i].ASN = info.ASN
+		rows[i].ASOrg = info.ASOrg
+	}
+}
+
+// printTextSnapshot writes a sorted, human-readable table to stdout.
+func printTextSnapshot(now time.Time, rows []snapshotRow, unit string) {
+	divisor := unitDivisor(unit)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n-- usage snapshot at %s --\n", now.Format(time.RFC3339))
+	fmt.Fprintf(&b, "%-20s %12s %12s %12s\n", "IP", "RX ("+unit+")", "TX ("+unit+")", "TOTAL ("+unit+")")
+	for _, r := range rows {
+		fmt.Fprintf(&b, "%-20s %12.2f %12.2f %12.2f\n",
+			r.IP, float64(r.RxBytes)/divisor, float64(r.TxBytes)/divisor, float64(r.Total)/divisor)
+	}
+
+	fmt.Print(b.String())
+}
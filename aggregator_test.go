@@ -0,0 +1,98 @@
+package main
+
+import "testing"
+
+func TestAggregatorAdd(t *testing.T) {
+	type op struct {
+		ip        string
+		direction string
+		size      uint64
+	}
+
+	cases := []struct {
+		name           string
+		ops            []op
+		wantRx, wantTx uint64
+	}{
+		{
+			name:   "single download",
+			ops:    []op{{"10.0.0.1", "Download", 100}},
+			wantRx: 100,
+		},
+		{
+			name: "download and upload accumulate",
+			ops: []op{
+				{"10.0.0.1", "Download", 100},
+				{"10.0.0.1", "Upload", 40},
+				{"10.0.0.1", "Download", 25},
+			},
+			wantRx: 125,
+			wantTx: 40,
+		},
+		{
+			name: "unknown direction is ignored",
+			ops: []op{
+				{"10.0.0.1", "Download", 10},
+				{"10.0.0.1", "sideways", 999},
+			},
+			wantRx: 10,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newAggregator()
+			for _, o := range tc.ops {
+				a.add(o.ip, o.direction, o.size)
+			}
+			u := a.usage["10.0.0.1"]
+			if u == nil {
+				t.Fatalf("no usage recorded for 10.0.0.1")
+			}
+			if u.rxBytes != tc.wantRx {
+				t.Errorf("rxBytes = %d, want %d", u.rxBytes, tc.wantRx)
+			}
+			if u.txBytes != tc.wantTx {
+				t.Errorf("txBytes = %d, want %d", u.txBytes, tc.wantTx)
+			}
+		})
+	}
+}
+
+func TestSortSnapshotRows(t *testing.T) {
+	base := []snapshotRow{
+		{IP: "10.0.0.2", RxBytes: 50, TxBytes: 10, Total: 60},
+		{IP: "10.0.0.1", RxBytes: 5, TxBytes: 5, Total: 10},
+		{IP: "10.0.0.3", RxBytes: 100, TxBytes: 0, Total: 100},
+	}
+
+	cases := []struct {
+		name      string
+		field     string
+		dir       string
+		wantOrder []string
+	}{
+		{"ip asc", "ip", "asc", []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}},
+		{"total desc default", "total", "desc", []string{"10.0.0.3", "10.0.0.2", "10.0.0.1"}},
+		{"rx asc", "rx", "asc", []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}},
+		{"tx desc", "tx", "desc", []string{"10.0.0.2", "10.0.0.1", "10.0.0.3"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rows := make([]snapshotRow, len(base))
+			copy(rows, base)
+			sortSnapshotRows(rows, tc.field, tc.dir)
+
+			got := make([]string, len(rows))
+			for i, r := range rows {
+				got[i] = r.IP
+			}
+			for i := range got {
+				if got[i] != tc.wantOrder[i] {
+					t.Fatalf("order = %v, want %v", got, tc.wantOrder)
+				}
+			}
+		})
+	}
+}
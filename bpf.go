@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// buildBPFFilter generates a libpcap filter expression that matches only
+// traffic to or from the tracked targets, so filtering happens in the
+// kernel instead of on every packet in processPacket. extra, if set, is
+// a user-supplied filter expression ANDed onto the generated one.
+func buildBPFFilter(targets *targetSet, extra string) string {
+	var terms []string
+
+	for ip := range targets.ips {
+		terms = append(terms, fmt.Sprintf("host %s", ip))
+	}
+	for _, n := range targets.subnets {
+		terms = append(terms, fmt.Sprintf("net %s", n.String()))
+	}
+
+	filter := strings.Join(terms, " or ")
+
+	extra = strings.TrimSpace(extra)
+	switch {
+	case filter == "":
+		return extra
+	case extra == "":
+		return filter
+	default:
+		return fmt.Sprintf("(%s) and (%s)", filter, extra)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestBuildBPFFilter(t *testing.T) {
+	cases := []struct {
+		name    string
+		targets *targetSet
+		extra   string
+		want    string
+	}{
+		{
+			name:    "single IP, no extra",
+			targets: resolveTargets("10.0.0.1"),
+			want:    "host 10.0.0.1",
+		},
+		{
+			name:    "single subnet, no extra",
+			targets: resolveTargets("192.168.1.0/24"),
+			want:    "net 192.168.1.0/24",
+		},
+		{
+			name:    "no targets, no extra",
+			targets: resolveTargets(""),
+			want:    "",
+		},
+		{
+			name:    "no targets, extra only",
+			targets: resolveTargets(""),
+			extra:   "tcp",
+			want:    "tcp",
+		},
+		{
+			name:    "targets and extra are ANDed together",
+			targets: resolveTargets("10.0.0.1"),
+			extra:   " tcp ",
+			want:    "(host 10.0.0.1) and (tcp)",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := buildBPFFilter(tc.targets, tc.extra); got != tc.want {
+				t.Errorf("buildBPFFilter() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuildBPFFilterMultipleTargetsOrTogether(t *testing.T) {
+	targets := resolveTargets("10.0.0.1,10.0.0.2,192.168.1.0/24")
+	got := buildBPFFilter(targets, "")
+
+	terms := strings.Split(got, " or ")
+	sort.Strings(terms)
+	want := []string{"host 10.0.0.1", "host 10.0.0.2", "net 192.168.1.0/24"}
+	if len(terms) != len(want) {
+		t.Fatalf("buildBPFFilter() = %q, want terms %v", got, want)
+	}
+	for i := range want {
+		if terms[i] != want[i] {
+			t.Errorf("terms = %v, want %v", terms, want)
+		}
+	}
+}
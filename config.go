@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// config holds every knob the CLI exposes, either set directly on the
+// command line or layered in from an optional JSON config file.
+type config struct {
+	Targets         string        `json:"targets"`
+	Iface           string        `json:"iface"`
+	OutPath         string        `json:"out"`
+	FlushInterval   time.Duration `json:"flush_interval"`
+	Unit            string        `json:"unit"`
+	SortField       string        `json:"sort_field"`
+	SortDir         string        `json:"sort_dir"`
+	BPFFilter       string        `json:"bpf"`
+	GeoCityDB       string        `json:"geo_city_db"`
+	GeoASNDB        string        `json:"geo_asn_db"`
+	FlowOutPath     string        `json:"flow_out"`
+	FlowIdleTimeout time.Duration `json:"flow_idle_timeout"`
+	MetricsAddr     string        `json:"metrics_addr"`
+	ListIfaces      bool          `json:"-"`
+	ConfigFile      string        `json:"-"`
+}
+
+// fileConfig mirrors config but with a string duration, since
+// encoding/json can't unmarshal into time.Duration directly.
+type fileConfig struct {
+	Targets         string `json:"targets"`
+	Iface           string `json:"iface"`
+	OutPath         string `json:"out"`
+	FlushInterval   string `json:"flush_interval"`
+	Unit            string `json:"unit"`
+	SortField       string `json:"sort_field"`
+	SortDir         string `json:"sort_dir"`
+	BPFFilter       string `json:"bpf"`
+	GeoCityDB       string `json:"geo_city_db"`
+	GeoASNDB        string `json:"geo_asn_db"`
+	FlowOutPath     string `json:"flow_out"`
+	FlowIdleTimeout string `json:"flow_idle_timeout"`
+	MetricsAddr     string `json:"metrics_addr"`
+}
+
+func parseConfig(args []string) (*config, error) {
+	fs := flag.NewFlagSet("netmon", flag.ContinueOnError)
+
+	cfg := &config{}
+	fs.StringVar(&cfg.Targets, "targets", "", "comma-separated IPs, CIDR ranges, or FQDNs to monitor")
+	fs.StringVar(&cfg.Iface, "iface", "", "name of the network interface to capture on")
+	fs.StringVar(&cfg.OutPath, "out", CSV, "output path for the aggregated report (.csv or .db)")
+	fs.DurationVar(&cfg.FlushInterval, "interval", 10*time.Second, "how often to aggregate counters and flush a report")
+	fs.StringVar(&cfg.Unit, "unit", "MB", "human-readable unit for text snapshots: KB, MB, GB, or TB")
+	fs.StringVar(&cfg.SortField, "sort", "total", "field to sort text snapshots by: ip, rx, tx, or total")
+	fs.StringVar(&cfg.SortDir, "sort-dir", "desc", "sort direction for text snapshots: asc or desc")
+	fs.StringVar(&cfg.BPFFilter, "bpf", "", "extra BPF filter expression, ANDed with the filter generated from -targets")
+	fs.StringVar(&cfg.GeoCityDB, "geodb", "", "path to a MaxMind GeoLite2-City database for country/city enrichment")
+	fs.StringVar(&cfg.GeoASNDB, "geo-asn-db", "", "path to a MaxMind GeoLite2-ASN database for ASN enrichment")
+	fs.StringVar(&cfg.FlowOutPath, "flow-out", "", "output path for per-flow reports (.csv or .json); disabled if empty")
+	fs.DurationVar(&cfg.FlowIdleTimeout, "flow-idle-timeout", 2*time.Minute, "how long a TCP flow may sit idle before it's swept and reported as timed out")
+	fs.StringVar(&cfg.MetricsAddr, "metrics-addr", "", "address to serve /metrics and /stats.json on (e.g. :9090); disabled if empty")
+	fs.BoolVar(&cfg.ListIfaces, "list-ifaces", false, "list available network interfaces and exit")
+	fs.StringVar(&cfg.ConfigFile, "config", "", "path to an optional JSON config file")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// -list-ifaces is a standalone action: it doesn't need -targets or
+	// -iface, so skip the config file and validation that would enforce
+	// them.
+	if cfg.ListIfaces {
+		return cfg, nil
+	}
+
+	if cfg.ConfigFile != "" {
+		set := make(map[string]bool)
+		fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		if err := applyConfigFile(cfg, set); err != nil {
+			return nil, fmt.Errorf("loading config file: %w", err)
+		}
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// applyConfigFile fills in any field the user didn't explicitly pass on
+// the command line from the JSON config file. Flags always win over the
+// config file so callers can still override it ad hoc.
+func applyConfigFile(cfg *config, setOnCLI map[string]bool) error {
+	data, err := os.ReadFile(cfg.ConfigFile)
+	if err != nil {
+		return err
+	}
+
+	var fc fileConfig
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return err
+	}
+
+	if fc.Targets != "" && !setOnCLI["targets"] {
+		cfg.Targets = fc.Targets
+	}
+	if fc.Iface != "" && !setOnCLI["iface"] {
+		cfg.Iface = fc.Iface
+	}
+	if fc.OutPath != "" && !setOnCLI["out"] {
+		cfg.OutPath = fc.OutPath
+	}
+	if fc.FlushInterval != "" && !setOnCLI["interval"] {
+		d, err := time.ParseDuration(fc.FlushInterval)
+		if err != nil {
+			return fmt.Errorf("invalid flush_interval %q: %w", fc.FlushInterval, err)
+		}
+		cfg.FlushInterval = d
+	}
+	if fc.Unit != "" && !setOnCLI["unit"] {
+		cfg.Unit = fc.Unit
+	}
+	if fc.SortField != "" && !setOnCLI["sort"] {
+		cfg.SortField = fc.SortField
+	}
+	if fc.SortDir != "" && !setOnCLI["sort-dir"] {
+		cfg.SortDir = fc.SortDir
+	}
+	if fc.BPFFilter != "" && !setOnCLI["bpf"] {
+		cfg.BPFFilter = fc.BPFFilter
+	}
+	if fc.GeoCityDB != "" && !setOnCLI["geodb"] {
+		cfg.GeoCityDB = fc.GeoCityDB
+	}
+	if fc.GeoASNDB != "" && !setOnCLI["geo-asn-db"] {
+		cfg.GeoASNDB = fc.GeoASNDB
+	}
+	if fc.FlowOutPath != "" && !setOnCLI["flow-out"] {
+		cfg.FlowOutPath = fc.FlowOutPath
+	}
+	if fc.FlowIdleTimeout != "" && !setOnCLI["flow-idle-timeout"] {
+		d, err := time.ParseDuration(fc.FlowIdleTimeout)
+		if err != nil {
+			return fmt.Errorf("invalid flow_idle_timeout %q: %w", fc.FlowIdleTimeout, err)
+		}
+		cfg.FlowIdleTimeout = d
+	}
+	if fc.MetricsAddr != "" && !setOnCLI["metrics-addr"] {
+		cfg.MetricsAddr = fc.MetricsAddr
+	}
+
+	return nil
+}
+
+func (c *config) validate() error {
+	if strings.TrimSpace(c.Targets) == "" {
+		return fmt.Errorf("no targets given: pass -targets or set \"targets\" in the config file")
+	}
+	if c.Iface == "" {
+		return fmt.Errorf("no interface given: pass -iface (use -list-ifaces to see available adapters)")
+	}
+	switch strings.ToUpper(c.Unit) {
+	case "KB", "MB", "GB", "TB":
+	default:
+		return fmt.Errorf("invalid unit %q: must be KB, MB, GB, or TB", c.Unit)
+	}
+	switch c.SortField {
+	case "ip", "rx", "tx", "total":
+	default:
+		return fmt.Errorf("invalid sort field %q: must be ip, rx, tx, or total", c.SortField)
+	}
+	switch c.SortDir {
+	case "asc", "desc":
+	default:
+		return fmt.Errorf("invalid sort direction %q: must be asc or desc", c.SortDir)
+	}
+	if c.FlushInterval <= 0 {
+		return fmt.Errorf("flush interval must be positive, got %s", c.FlushInterval)
+	}
+	return nil
+}
+
+// unitDivisor returns the byte divisor for a human-readable unit name.
+func unitDivisor(unit string) float64 {
+	switch strings.ToUpper(unit) {
+	case "KB":
+		return 1 << 10
+	case "GB":
+		return 1 << 30
+	case "TB":
+		return 1 << 40
+	default: // MB
+		return 1 << 20
+	}
+}
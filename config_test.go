@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func validConfig() *config {
+	return &config{
+		Targets:       "10.0.0.1",
+		Iface:         "eth0",
+		OutPath:       CSV,
+		FlushInterval: 10 * time.Second,
+		Unit:          "MB",
+		SortField:     "total",
+		SortDir:       "desc",
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		mutate  func(*config)
+		wantErr bool
+	}{
+		{"valid config", func(c *config) {}, false},
+		{"no targets", func(c *config) { c.Targets = "  " }, true},
+		{"no iface", func(c *config) { c.Iface = "" }, true},
+		{"bad unit", func(c *config) { c.Unit = "PB" }, true},
+		{"unit is case-insensitive", func(c *config) { c.Unit = "mb" }, false},
+		{"bad sort field", func(c *config) { c.SortField = "bogus" }, true},
+		{"bad sort dir", func(c *config) { c.SortDir = "sideways" }, true},
+		{"non-positive interval", func(c *config) { c.FlushInterval = 0 }, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := validConfig()
+			tc.mutate(cfg)
+			err := cfg.validate()
+			if tc.wantErr && err == nil {
+				t.Fatalf("validate() = nil, want error")
+			}
+			if !tc.wantErr && err != nil {
+				t.Fatalf("validate() = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestApplyConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "netmon.json")
+	const body = `{"targets": "10.0.0.2", "iface": "eth1", "unit": "GB", "flush_interval": "5s"}`
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("writing config file: %v", err)
+	}
+
+	t.Run("fills in fields not set on the CLI", func(t *testing.T) {
+		cfg := &config{ConfigFile: path}
+		if err := applyConfigFile(cfg, map[string]bool{}); err != nil {
+			t.Fatalf("applyConfigFile() = %v", err)
+		}
+		if cfg.Targets != "10.0.0.2" || cfg.Iface != "eth1" || cfg.Unit != "GB" {
+			t.Fatalf("unexpected config after applying file: %+v", cfg)
+		}
+		if cfg.FlushInterval != 5*time.Second {
+			t.Fatalf("FlushInterval = %s, want 5s", cfg.FlushInterval)
+		}
+	})
+
+	t.Run("CLI flags win over the config file", func(t *testing.T) {
+		cfg := &config{ConfigFile: path, Targets: "10.0.0.3"}
+		if err := applyConfigFile(cfg, map[string]bool{"targets": true}); err != nil {
+			t.Fatalf("applyConfigFile() = %v", err)
+		}
+		if cfg.Targets != "10.0.0.3" {
+			t.Fatalf("Targets = %q, want CLI value preserved", cfg.Targets)
+		}
+	})
+
+	t.Run("invalid flush_interval is rejected", func(t *testing.T) {
+		badPath := filepath.Join(dir, "bad.json")
+		if err := os.WriteFile(badPath, []byte(`{"flush_interval": "not-a-duration"}`), 0o644); err != nil {
+			t.Fatalf("writing config file: %v", err)
+		}
+		cfg := &config{ConfigFile: badPath}
+		if err := applyConfigFile(cfg, map[string]bool{}); err == nil {
+			t.Fatalf("applyConfigFile() = nil, want error")
+		}
+	})
+
+	t.Run("missing file returns an error", func(t *testing.T) {
+		cfg := &config{ConfigFile: filepath.Join(dir, "missing.json")}
+		if err := applyConfigFile(cfg, map[string]bool{}); err == nil {
+			t.Fatalf("applyConfigFile() = nil, want error")
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// flowReportWriter persists one completed flowRecord at a time.
+// newFlowReportWriter picks CSV or JSON Lines based on the output path's
+// extension.
+type flowReportWriter interface {
+	writeFlow(rec *flowRecord) error
+	close() error
+}
+
+func newFlowReportWriter(path string) (flowReportWriter, error) {
+	if strings.HasSuffix(strings.ToLower(path), ".json") {
+		return newJSONFlowWriter(path)
+	}
+	return newCSVFlowWriter(path)
+}
+
+// csvFlowWriter appends one row per completed flow.
+type csvFlowWriter struct {
+	file   *os.File
+	writer *csv.Writer
+}
+
+func newCSVFlowWriter(path string) (*csvFlowWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating flow CSV file: %w", err)
+	}
+
+	w := csv.NewWriter(file)
+	w.Comma = ';'
+
+	headers := []string{
+		"SrcIP", "SrcPort", "DstIP", "DstPort",
+		"FirstSeen", "LastSeen", "DurationSeconds",
+		"BytesClientToServer", "BytesServerToClient", "Retransmits", "CloseReason",
+	}
+	if err := w.Write(headers); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("writing flow CSV header: %w", err)
+	}
+	w.Flush()
+
+	return &csvFlowWriter{file: file, writer: w}, nil
+}
+
+func (c *csvFlowWriter) writeFlow(rec *flowRecord) error {
+	record := []string{
+		rec.Key.SrcIP,
+		strconv.FormatUint(uint64(rec.Key.SrcPort), 10),
+		rec.Key.DstIP,
+		strconv.FormatUint(uint64(rec.Key.DstPort), 10),
+		rec.FirstSeen.Format(time.RFC3339),
+		rec.LastSeen.Format(time.RFC3339),
+		strconv.FormatFloat(rec.LastSeen.Sub(rec.FirstSeen).Seconds(), 'f', 3, 64),
+		strconv.FormatUint(rec.BytesClientToServer, 10),
+		strconv.FormatUint(rec.BytesServerToClient, 10),
+		strconv.Itoa(rec.Retransmits),
+		rec.CloseReason,
+	}
+	if err := c.writer.Write(record); err != nil {
+		return fmt.Errorf("writing flow row: %w", err)
+	}
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+func (c *csvFlowWriter) close() error {
+	c.writer.Flush()
+	return c.file.Close()
+}
+
+// jsonFlowWriter appends one JSON object per line, which is easier to
+// stream into log-aggregation tools than a single JSON array.
+type jsonFlowWriter struct {
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func newJSONFlowWriter(path string) (*jsonFlowWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating flow JSON file: %w", err)
+	}
+	return &jsonFlowWriter{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+func (j *jsonFlowWriter) writeFlow(rec *flowRecord) error {
+	return j.encoder.Encode(rec)
+}
+
+func (j *jsonFlowWriter) close() error {
+	return j.file.Close()
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/reassembly"
+)
+
+// flowKey identifies a TCP connection by its 5-tuple (the protocol is
+// implicitly TCP, since that's all flowTracker reassembles).
+type flowKey struct {
+	SrcIP, DstIP     string
+	SrcPort, DstPort uint16
+}
+
+// flowRecord is the per-connection accounting flowTracker reports once a
+// flow closes or is timed out by a sweep.
+type flowRecord struct {
+	Key                 flowKey
+	FirstSeen           time.Time
+	LastSeen            time.Time
+	BytesClientToServer uint64
+	BytesServerToClient uint64
+	Retransmits         int
+	CloseReason         string // "FIN", "RST", or "timeout"
+
+	finSeen bool // set once a FIN is observed, to tell a real close from an idle timeout
+}
+
+// flowTracker groups packets into per-5-tuple flows with TCP stream
+// reassembly, so completed connections can be reported with accurate
+// byte totals and a close reason instead of one row per packet.
+type flowTracker struct {
+	mu          sync.Mutex
+	flows       map[flowKey]*flowRecord
+	writer      flowReportWriter
+	idleTimeout time.Duration
+
+	// asmMu serializes access to assembler: reassembly.Assembler isn't
+	// safe for concurrent use, but feed (the capture goroutine) and
+	// sweep (runFlowSweeps's goroutine) both drive it.
+	asmMu     sync.Mutex
+	assembler *reassembly.Assembler
+}
+
+func newFlowTracker(writer flowReportWriter, idleTimeout time.Duration) *flowTracker {
+	ft := &flowTracker{
+		flows:       make(map[flowKey]*flowRecord),
+		writer:      writer,
+		idleTimeout: idleTimeout,
+	}
+	pool := reassembly.NewStreamPool(&flowStreamFactory{tracker: ft})
+	ft.assembler = reassembly.NewAssembler(pool)
+	return ft
+}
+
+// feed hands a TCP packet to the reassembler. It's a no-op for packets
+// without a TCP layer, so callers can pass every captured packet through.
+func (ft *flowTracker) feed(packet gopacket.Packet) {
+	tcpLayer := packet.Layer(layers.LayerTypeTCP)
+	if tcpLayer == nil || packet.NetworkLayer() == nil {
+		return
+	}
+	tcp := tcpLayer.(*layers.TCP)
+	ctx := &captureContext{ci: packet.Metadata().CaptureInfo}
+
+	ft.asmMu.Lock()
+	defer ft.asmMu.Unlock()
+	ft.assembler.AssembleWithContext(packet.NetworkLayer().NetworkFlow(), tcp, ctx)
+}
+
+// sweep closes and reports any flow that's been idle longer than
+// idleTimeout, so long-lived captures don't hold stale connections in
+// memory forever.
+func (ft *flowTracker) sweep(now time.Time) {
+	ft.asmMu.Lock()
+	defer ft.asmMu.Unlock()
+	ft.assembler.FlushCloseOlderThan(now.Add(-ft.idleTimeout))
+}
+
+// recordClose reports and forgets the flow for key, tagging it with why
+// it closed ("FIN", "RST", or "timeout").
+func (ft *flowTracker) recordClose(key flowKey, reason string) {
+	ft.mu.Lock()
+	rec, ok := ft.flows[key]
+	if ok {
+		delete(ft.flows, key)
+	}
+	ft.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	rec.CloseReason = reason
+
+	if ft.writer == nil {
+		return
+	}
+	if err := ft.writer.writeFlow(rec); err != nil {
+		log.Println("error writing flow report:", err)
+	}
+}
+
+func (ft *flowTracker) close() error {
+	if ft.writer == nil {
+		return nil
+	}
+	return ft.writer.close()
+}
+
+// captureContext implements reassembly.AssemblerContext, giving the
+// assembler each packet's capture timestamp.
+type captureContext struct {
+	ci gopacket.CaptureInfo
+}
+
+func (c *captureContext) GetCaptureInfo() gopacket.CaptureInfo { return c.ci }
+
+// flowStreamFactory creates a flowStream for each new TCP connection the
+// assembler observes.
+type flowStreamFactory struct {
+	tracker *flowTracker
+}
+
+func (f *flowStreamFactory) New(net, transport gopacket.Flow, tcp *layers.TCP, ac reassembly.AssemblerContext) reassembly.Stream {
+	key := flowKey{
+		SrcIP:   net.Src().String(),
+		DstIP:   net.Dst().String(),
+		SrcPort: uint16(tcp.SrcPort),
+		DstPort: uint16(tcp.DstPort),
+	}
+
+	now := ac.GetCaptureInfo().Timestamp
+	f.tracker.mu.Lock()
+	f.tracker.flows[key] = &flowRecord{Key: key, FirstSeen: now, LastSeen: now}
+	f.tracker.mu.Unlock()
+
+	return &flowStream{tracker: f.tracker, key: key}
+}
+
+// flowStream implements reassembly.Stream, tallying bytes per direction
+// and detecting FIN/RST close reasons for a single TCP connection.
+type flowStream struct {
+	tracker *flowTracker
+	key     flowKey
+}
+
+func (s *flowStream) Accept(tcp *layers.TCP, ci gopacket.CaptureInfo, dir reassembly.TCPFlowDirection, nextSeq reassembly.Sequence, start *bool, ac reassembly.AssemblerContext) bool {
+	s.tracker.mu.Lock()
+	if rec, ok := s.tracker.flows[s.key]; ok {
+		rec.LastSeen = ci.Timestamp
+		if tcp.FIN {
+			rec.finSeen = true
+		}
+	}
+	s.tracker.mu.Unlock()
+
+	if tcp.RST {
+		s.tracker.recordClose(s.key, "RST")
+	}
+
+	return true
+}
+
+func (s *flowStream) ReassembledSG(sg reassembly.ScatterGather, ac reassembly.AssemblerContext) {
+	length, _ := sg.Lengths()
+	dir, _, _, skip := sg.Info()
+	if skip > 0 {
+		return
+	}
+	stats := sg.Stats()
+	info := sg.CaptureInfo(0)
+
+	s.tracker.mu.Lock()
+	defer s.tracker.mu.Unlock()
+
+	rec, ok := s.tracker.flows[s.key]
+	if !ok {
+		return
+	}
+	if dir == reassembly.TCPDirClientToServer {
+		rec.BytesClientToServer += uint64(length)
+	} else {
+		rec.BytesServerToClient += uint64(length)
+	}
+	rec.Retransmits += stats.OverlapPackets
+	rec.LastSeen = info.Timestamp
+}
+
+// ReassemblyComplete fires on FIN, on an RST the library hasn't already
+// had reported to us via Accept, and on flows force-closed by sweep's
+// FlushCloseOlderThan. Only the FIN case leaves finSeen set, so anything
+// else here is an idle flow that timed out.
+func (s *flowStream) ReassemblyComplete(ac reassembly.AssemblerContext) bool {
+	reason := "timeout"
+
+	s.tracker.mu.Lock()
+	if rec, ok := s.tracker.flows[s.key]; ok && rec.finSeen {
+		reason = "FIN"
+	}
+	s.tracker.mu.Unlock()
+
+	s.tracker.recordClose(s.key, reason)
+	return true
+}
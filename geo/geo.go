@@ -0,0 +1,100 @@
+// Package geo resolves remote IPs to geographic and ASN information
+// using local MaxMind GeoLite2 databases, for reports that break data
+// usage down by country/city/AS instead of just IP.
+package geo
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// Info is what we care about out of a GeoLite2 lookup. Fields are left
+// zero-valued when the underlying database doesn't have an answer.
+type Info struct {
+	Country string
+	City    string
+	ASN     uint
+	ASOrg   string
+}
+
+// Resolver looks up Info for IPs against one or two GeoLite2 databases
+// (city and ASN are shipped as separate MaxMind databases), caching
+// results so a busy flow doesn't re-query the mmdb on every packet.
+type Resolver struct {
+	cityDB *geoip2.Reader
+	asnDB  *geoip2.Reader
+	cache  sync.Map // net.IP.String() -> Info
+}
+
+// NewResolver opens the given GeoLite2 databases. asnDBPath may be empty
+// if only country/city enrichment is wanted.
+func NewResolver(cityDBPath, asnDBPath string) (*Resolver, error) {
+	r := &Resolver{}
+
+	if cityDBPath != "" {
+		db, err := geoip2.Open(cityDBPath)
+		if err != nil {
+			return nil, fmt.Errorf("opening GeoLite2 city database: %w", err)
+		}
+		r.cityDB = db
+	}
+
+	if asnDBPath != "" {
+		db, err := geoip2.Open(asnDBPath)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("opening GeoLite2 ASN database: %w", err)
+		}
+		r.asnDB = db
+	}
+
+	return r, nil
+}
+
+// Lookup returns geo/ASN info for ip, serving from cache when possible.
+func (r *Resolver) Lookup(ip net.IP) Info {
+	key := ip.String()
+	if cached, ok := r.cache.Load(key); ok {
+		return cached.(Info)
+	}
+
+	var info Info
+
+	if r.cityDB != nil {
+		if city, err := r.cityDB.City(ip); err == nil {
+			info.Country = city.Country.IsoCode
+			if len(city.City.Names) > 0 {
+				info.City = city.City.Names["en"]
+			}
+		}
+	}
+
+	if r.asnDB != nil {
+		if asn, err := r.asnDB.ASN(ip); err == nil {
+			info.ASN = asn.AutonomousSystemNumber
+			info.ASOrg = asn.AutonomousSystemOrganization
+		}
+	}
+
+	r.cache.Store(key, info)
+	return info
+}
+
+// Close releases the underlying database handles.
+func (r *Resolver) Close() error {
+	var firstErr error
+	if r.cityDB != nil {
+		if err := r.cityDB.Close(); err != nil {
+			firstErr = err
+		}
+	}
+	if r.asnDB != nil {
+		if err := r.asnDB.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,39 @@
+package geo
+
+import (
+	"net"
+	"testing"
+)
+
+// Without the cityDB/asnDB populated, Lookup always returns a zero Info,
+// but it should still cache that result per IP rather than recomputing it.
+func TestResolverLookupCaches(t *testing.T) {
+	r := &Resolver{}
+
+	ip := net.ParseIP("8.8.8.8")
+	got := r.Lookup(ip)
+	if got != (Info{}) {
+		t.Fatalf("Lookup() = %+v, want zero Info", got)
+	}
+
+	if _, ok := r.cache.Load(ip.String()); !ok {
+		t.Fatalf("Lookup() did not cache a result for %s", ip)
+	}
+
+	if again := r.Lookup(ip); again != got {
+		t.Fatalf("second Lookup() = %+v, want cached %+v", again, got)
+	}
+}
+
+func TestResolverLookupCachesIndependently(t *testing.T) {
+	r := &Resolver{}
+
+	r.cache.Store("1.1.1.1", Info{Country: "US"})
+
+	if got := r.Lookup(net.ParseIP("1.1.1.1")); got.Country != "US" {
+		t.Fatalf("Lookup(1.1.1.1) = %+v, want cached Country=US", got)
+	}
+	if got := r.Lookup(net.ParseIP("2.2.2.2")); got != (Info{}) {
+		t.Fatalf("Lookup(2.2.2.2) = %+v, want zero Info for an uncached IP", got)
+	}
+}
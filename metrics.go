@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// newMetricsServer builds an HTTP server exposing the aggregator's
+// current counters as Prometheus text format on /metrics and as JSON on
+// /stats.json. It's started optionally, when -metrics-addr is set.
+func newMetricsServer(addr string, agg *aggregator) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		serveMetrics(w, agg)
+	})
+	mux.HandleFunc("/stats.json", func(w http.ResponseWriter, r *http.Request) {
+		serveStatsJSON(w, agg)
+	})
+
+	return &http.Server{Addr: addr, Handler: mux}
+}
+
+func serveMetrics(w http.ResponseWriter, agg *aggregator) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	rows := agg.metricsSnapshot()
+
+	fmt.Fprintln(w, "# HELP netmon_bytes_total Total bytes observed per tracked IP and direction.")
+	fmt.Fprintln(w, "# TYPE netmon_bytes_total counter")
+	for _, r := range rows {
+		fmt.Fprintf(w, "netmon_bytes_total{ip=%q,direction=\"rx\"} %d\n", r.IP, r.RxBytes)
+		fmt.Fprintf(w, "netmon_bytes_total{ip=%q,direction=\"tx\"} %d\n", r.IP, r.TxBytes)
+	}
+
+	fmt.Fprintln(w, "# HELP netmon_packets_total Total packets observed per tracked IP and direction.")
+	fmt.Fprintln(w, "# TYPE netmon_packets_total counter")
+	for _, r := range rows {
+		fmt.Fprintf(w, "netmon_packets_total{ip=%q,direction=\"rx\"} %d\n", r.IP, r.RxPackets)
+		fmt.Fprintf(w, "netmon_packets_total{ip=%q,direction=\"tx\"} %d\n", r.IP, r.TxPackets)
+	}
+}
+
+func serveStatsJSON(w http.ResponseWriter, agg *aggregator) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(agg.metricsSnapshot()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -1,23 +1,24 @@
 package main
 
 import (
-	"bufio"
-	"encoding/csv"
+	"context"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcap"
-)
 
-const CSV = "csm2networkreport.csv"
+	"netmon/geo"
+)
 
 var (
 	snapshotLenght int32         = 1024
@@ -25,76 +26,244 @@ var (
 	timeout        time.Duration = 30 * time.Second
 )
 
-var trackedIPs = make(map[string]struct{})
+func main() {
+	cfg, err := parseConfig(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if cfg.ListIfaces {
+		listInterfaces()
+		return
+	}
 
-var fileMutex sync.Mutex
-var csvWriter *csv.Writer
-var csvFile *os.File
+	trackedIPs := resolveTargets(cfg.Targets)
+	if trackedIPs.empty() {
+		log.Fatal("You didn't enter any valid IPs, CIDR ranges, or resolvable FQDNs.")
+	}
+	fmt.Printf("Tracking: %s\n", trackedIPs)
 
-func writeToCSVRow(timestamp time.Time, ip, direction string, size uint64) {
-	fileMutex.Lock()
-	defer fileMutex.Unlock()
+	agg := newAggregator()
 
-	record := []string{
-		timestamp.Format(time.RFC3339),
-		ip,
-		direction,
-		strconv.FormatUint(size, 10),
+	writer, err := newReportWriter(cfg.OutPath)
+	if err != nil {
+		log.Fatal(err)
 	}
+	defer func() {
+		if err := writer.close(); err != nil {
+			log.Println("error closing report writer:", err)
+		}
+	}()
 
-	if err := csvWriter.Write(record); err != nil {
-		log.Println("Error writing row:", err)
+	var geoResolver *geo.Resolver
+	if cfg.GeoCityDB != "" || cfg.GeoASNDB != "" {
+		geoResolver, err = geo.NewResolver(cfg.GeoCityDB, cfg.GeoASNDB)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer geoResolver.Close()
+	}
+
+	stopFlusher := make(chan struct{})
+	flusherDone := make(chan struct{})
+	f := newFlusher(agg, writer, geoResolver, cfg)
+	go func() {
+		f.run(stopFlusher)
+		close(flusherDone)
+	}()
+	defer func() {
+		close(stopFlusher)
+		<-flusherDone
+	}()
+
+	if cfg.MetricsAddr != "" {
+		metricsServer := newMetricsServer(cfg.MetricsAddr, agg)
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Println("metrics server error:", err)
+			}
+		}()
+		defer metricsServer.Close()
+		fmt.Printf("Serving metrics on http://%s/metrics and /stats.json\n", cfg.MetricsAddr)
+	}
+
+	var flowTrack *flowTracker
+	if cfg.FlowOutPath != "" {
+		flowWriter, err := newFlowReportWriter(cfg.FlowOutPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			if err := flowWriter.close(); err != nil {
+				log.Println("error closing flow report writer:", err)
+			}
+		}()
+
+		flowTrack = newFlowTracker(flowWriter, cfg.FlowIdleTimeout)
+
+		stopSweep := make(chan struct{})
+		sweepDone := make(chan struct{})
+		go func() {
+			runFlowSweeps(flowTrack, cfg.FlushInterval, stopSweep)
+			close(sweepDone)
+		}()
+		defer func() {
+			close(stopSweep)
+			<-sweepDone
+		}()
+	}
+
+	handle, err := pcap.OpenLive(cfg.Iface, snapshotLenght, promiscuous, timeout)
+	if err != nil {
+		log.Fatalf("Error accessing adapter %s: %v", cfg.Iface, err)
+	}
+	var closeHandleOnce sync.Once
+	closeHandle := func() { closeHandleOnce.Do(handle.Close) }
+	defer closeHandle()
+
+	if filter := buildBPFFilter(trackedIPs, cfg.BPFFilter); filter != "" {
+		if err := handle.SetBPFFilter(filter); err != nil {
+			log.Fatalf("Error setting BPF filter %q: %v", filter, err)
+		}
+		fmt.Printf("Applied BPF filter: %s\n", filter)
+	}
+
+	fmt.Printf("Monitoring adapter: %s\n", cfg.Iface)
+	fmt.Printf("Flushing a report to %s every %s.\n", cfg.OutPath, cfg.FlushInterval)
+	fmt.Printf("Press Ctrl+C to stop.\n\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	go func() {
+		<-sigCh
+		fmt.Println("\nShutting down...")
+		cancel()
+		// Unblocks packetSource.Packets() so the capture loop below can
+		// exit and the deferred flushes/closes above run reliably.
+		closeHandle()
+	}()
+
+	packetSource := gopacket.NewPacketSource(handle, handle.LinkType())
+	packets := packetSource.Packets()
+
+captureLoop:
+	for {
+		select {
+		case packet, ok := <-packets:
+			if !ok {
+				break captureLoop
+			}
+			processPacket(agg, trackedIPs, packet)
+			if flowTrack != nil {
+				flowTrack.feed(packet)
+			}
+		case <-ctx.Done():
+			break captureLoop
+		}
 	}
-	// Flush data to disk immediately to ensure logs are up-to-date.
-	csvWriter.Flush()
 }
 
-func processPacket(packet gopacket.Packet) {
-	ipLayer := packet.Layer(layers.LayerTypeIPv4)
-	if ipLayer == nil {
-		return
+// listInterfaces prints the network adapters pcap can see, for use with
+// -iface.
+func listInterfaces() {
+	devices, err := pcap.FindAllDevs()
+	if err != nil {
+		log.Fatal("Error finding network adapters:", err)
 	}
-	ip, _ := ipLayer.(*layers.IPv4)
 
-	srcIP := ip.SrcIP.String()
-	dstIP := ip.DstIP.String()
-	packetSize := uint64(len(packet.Data()))
+	fmt.Println("Available network adapters:")
+	for i, dev := range devices {
+		description := dev.Description
+		if description == "" {
+			description = dev.Name
+		}
+		fmt.Printf("%d: %s (%s)\n", i, dev.Name, description)
+	}
+}
 
-	if _, ok := trackedIPs[srcIP]; ok {
-		writeToCSVRow(time.Now(), srcIP, "Download", packetSize)
-		fmt.Printf("Inbound traffic (Download) from '%s' (size: %d bytes)\n", srcIP, packetSize)
-		return
+// runFlowSweeps periodically times out and reports idle flows until stop
+// is closed.
+func runFlowSweeps(ft *flowTracker, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			ft.sweep(now)
+		case <-stop:
+			return
+		}
 	}
+}
 
-	if _, ok := trackedIPs[dstIP]; ok {
-		writeToCSVRow(time.Now(), dstIP, "Upload", packetSize)
-		fmt.Printf("Outbound traffic (Upload) to '%s' (size: %d bytes)\n", dstIP, packetSize)
-		return
+// targetSet is the set of things processPacket matches packets against:
+// individual IPs resolved exactly via a map, plus CIDR ranges checked
+// with net.IPNet.Contains.
+type targetSet struct {
+	ips     map[string]struct{}
+	subnets []*net.IPNet
+}
+
+// contains reports whether ip falls within the tracked set, either as an
+// exact match or inside one of the tracked CIDR ranges.
+func (t *targetSet) contains(ip net.IP) bool {
+	if _, ok := t.ips[ip.String()]; ok {
+		return true
+	}
+	for _, n := range t.subnets {
+		if n.Contains(ip) {
+			return true
+		}
 	}
+	return false
 }
 
-func main() {
+func (t *targetSet) empty() bool {
+	return len(t.ips) == 0 && len(t.subnets) == 0
+}
 
-	fmt.Println("Enter IP addresses or FQDNs to monitor (comma-separated):")
-	reader := bufio.NewReader(os.Stdin)
-	input, err := reader.ReadString('\n')
-	if err != nil {
-		log.Fatal("Error reading input:", err)
+func (t *targetSet) String() string {
+	parts := make([]string, 0, len(t.ips)+len(t.subnets))
+	for ip := range t.ips {
+		parts = append(parts, ip)
+	}
+	for _, n := range t.subnets {
+		parts = append(parts, n.String())
 	}
+	return strings.Join(parts, ", ")
+}
 
-	rawInputList := strings.Split(strings.TrimSpace(input), ",")
-	var resolvedIPs []string
+// resolveTargets parses a comma-separated list of IPs, CIDR ranges, and
+// FQDNs into the set of things to track.
+func resolveTargets(raw string) *targetSet {
+	targets := &targetSet{ips: make(map[string]struct{})}
 
-	for _, entry := range rawInputList {
+	for _, entry := range strings.Split(raw, ",") {
 		trimmedEntry := strings.TrimSpace(entry)
 		if trimmedEntry == "" {
 			continue
 		}
 
+		// A CIDR range gets tracked as a subnet rather than an exact IP.
+		if strings.Contains(trimmedEntry, "/") {
+			_, ipNet, err := net.ParseCIDR(trimmedEntry)
+			if err != nil {
+				fmt.Printf("Invalid CIDR range '%s': %v\n", trimmedEntry, err)
+				continue
+			}
+			targets.subnets = append(targets.subnets, ipNet)
+			continue
+		}
+
 		// Check if the entry is a valid IP address.
 		if net.ParseIP(trimmedEntry) != nil {
-			trackedIPs[trimmedEntry] = struct{}{}
-			resolvedIPs = append(resolvedIPs, trimmedEntry)
+			targets.ips[trimmedEntry] = struct{}{}
 			continue
 		}
 
@@ -108,86 +277,45 @@ func main() {
 
 		// Add all resolved IP addresses to the tracking list.
 		for _, ip := range ips {
-			trackedIPs[ip.String()] = struct{}{}
-			resolvedIPs = append(resolvedIPs, ip.String())
+			targets.ips[ip.String()] = struct{}{}
 		}
 	}
 
-	if len(trackedIPs) == 0 {
-		log.Fatal("You didn't enter any valid IPs or resolvable FQDNs.")
-	}
-
-	devices, err := pcap.FindAllDevs()
-	if err != nil {
-		log.Fatal("Error finding network adapters:", err)
-	}
-
-	fmt.Println("\nAvailable network adapters:")
-	for i, dev := range devices {
-		description := dev.Description
-		if description == "" {
-			description = dev.Name
-		}
-		fmt.Printf("%d: %s\n", i, description)
-	}
-	fmt.Println()
-
-	fmt.Print("Enter the number of the adapter to monitor: ")
-	input, err = reader.ReadString('\n')
-	if err != nil {
-		log.Fatal("Error reading adapter choice:", err)
-	}
-
-	choice, err := strconv.Atoi(strings.TrimSpace(input))
-	if err != nil || choice < 0 || choice >= len(devices) {
-		log.Fatal("Invalid choice. Please enter a valid number.")
-	}
-
-	selectedDevice := devices[choice]
-	fmt.Printf("Monitoring adapter: %s\n", selectedDevice.Description)
-	fmt.Printf("Tracking resolved IPs: %v\n", resolvedIPs)
-	fmt.Printf("Press Ctrl+C to stop.\n\n")
+	return targets
+}
 
-	if err := initCSVFile(); err != nil {
-		log.Fatal(err)
+func processPacket(agg *aggregator, trackedIPs *targetSet, packet gopacket.Packet) {
+	srcIP, dstIP := packetIPs(packet)
+	if srcIP == nil || dstIP == nil {
+		return
 	}
 
-	// Ensure the CSV file is properly closed when exiting program
-	defer func() {
-		csvWriter.Flush()
-		csvFile.Close()
-		log.Println("Writing to CSV completed.")
-	}()
+	packetSize := uint64(len(packet.Data()))
 
-	// Open the selected network device for packet capture
-	handle, err := pcap.OpenLive(selectedDevice.Name, snapshotLenght, promiscuous, timeout)
-	if err != nil {
-		log.Fatalf("Error accessing adapter %s: %v", selectedDevice.Name, err)
+	if trackedIPs.contains(srcIP) {
+		agg.add(srcIP.String(), "Download", packetSize)
+		fmt.Printf("Inbound traffic (Download) from '%s' (size: %d bytes)\n", srcIP, packetSize)
+		return
 	}
-	defer handle.Close()
 
-	// Start processing packets
-	packetSource := gopacket.NewPacketSource(handle, handle.LinkType())
-	for packet := range packetSource.Packets() {
-		processPacket(packet)
+	if trackedIPs.contains(dstIP) {
+		agg.add(dstIP.String(), "Upload", packetSize)
+		fmt.Printf("Outbound traffic (Upload) to '%s' (size: %d bytes)\n", dstIP, packetSize)
+		return
 	}
 }
 
-// Initializing CSV file
-func initCSVFile() error {
-	var err error
-	csvFile, err = os.Create(CSV)
-	if err != nil {
-		return fmt.Errorf("error creating CSV file: %w", err)
+// packetIPs extracts the source and destination addresses from whichever
+// IP layer is present, IPv4 or IPv6. It returns nil, nil for non-IP
+// packets (ARP and the like), which processPacket silently drops.
+func packetIPs(packet gopacket.Packet) (src, dst net.IP) {
+	if ipLayer := packet.Layer(layers.LayerTypeIPv4); ipLayer != nil {
+		ip := ipLayer.(*layers.IPv4)
+		return ip.SrcIP, ip.DstIP
 	}
-
-	csvWriter = csv.NewWriter(csvFile)
-	csvWriter.Comma = ';'
-
-	headers := []string{"Timestamp", "IP", "Traffic", "Bytes"}
-	if err := csvWriter.Write(headers); err != nil {
-		return fmt.Errorf("error writing header to CSV: %w", err) // Go community is advising that error strings should not be capitalized
+	if ipLayer := packet.Layer(layers.LayerTypeIPv6); ipLayer != nil {
+		ip := ipLayer.(*layers.IPv6)
+		return ip.SrcIP, ip.DstIP
 	}
-	csvWriter.Flush()
-	return nil
+	return nil, nil
 }
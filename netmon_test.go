@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+func TestResolveTargets(t *testing.T) {
+	cases := []struct {
+		name        string
+		raw         string
+		wantIPs     []string
+		wantSubnets []string
+	}{
+		{"single IP", "10.0.0.1", []string{"10.0.0.1"}, nil},
+		{
+			name:    "multiple IPs with whitespace",
+			raw:     "10.0.0.1, 10.0.0.2 ,10.0.0.3",
+			wantIPs: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+		},
+		{"blank entries are skipped", "10.0.0.1,,", []string{"10.0.0.1"}, nil},
+		{"empty string yields no targets", "", nil, nil},
+		{"unresolvable FQDN is skipped", "this-fqdn-does-not-exist.invalid", nil, nil},
+		{"CIDR range is tracked as a subnet", "192.168.1.0/24", nil, []string{"192.168.1.0/24"}},
+		{"invalid CIDR range is skipped", "10.0.0.0/99", nil, nil},
+		{
+			name:        "mix of IP and CIDR",
+			raw:         "10.0.0.1,192.168.1.0/24",
+			wantIPs:     []string{"10.0.0.1"},
+			wantSubnets: []string{"192.168.1.0/24"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := resolveTargets(tc.raw)
+			if len(got.ips) != len(tc.wantIPs) {
+				t.Fatalf("resolveTargets(%q).ips = %v, want %v", tc.raw, got.ips, tc.wantIPs)
+			}
+			for _, ip := range tc.wantIPs {
+				if _, ok := got.ips[ip]; !ok {
+					t.Errorf("resolveTargets(%q).ips missing %q, got %v", tc.raw, ip, got.ips)
+				}
+			}
+			if len(got.subnets) != len(tc.wantSubnets) {
+				t.Fatalf("resolveTargets(%q).subnets = %v, want %v", tc.raw, got.subnets, tc.wantSubnets)
+			}
+			for i, want := range tc.wantSubnets {
+				if got.subnets[i].String() != want {
+					t.Errorf("resolveTargets(%q).subnets[%d] = %s, want %s", tc.raw, i, got.subnets[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestTargetSetContains(t *testing.T) {
+	targets := resolveTargets("10.0.0.1,192.168.1.0/24")
+
+	cases := []struct {
+		name string
+		ip   string
+		want bool
+	}{
+		{"exact IP match", "10.0.0.1", true},
+		{"IP inside tracked subnet", "192.168.1.42", true},
+		{"IP outside everything tracked", "8.8.8.8", false},
+		{"IP just outside the subnet", "192.168.2.1", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := targets.contains(net.ParseIP(tc.ip)); got != tc.want {
+				t.Errorf("contains(%s) = %v, want %v", tc.ip, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTargetSetEmpty(t *testing.T) {
+	if !resolveTargets("").empty() {
+		t.Errorf("resolveTargets(\"\").empty() = false, want true")
+	}
+	if resolveTargets("10.0.0.1").empty() {
+		t.Errorf("resolveTargets(\"10.0.0.1\").empty() = true, want false")
+	}
+	if resolveTargets("10.0.0.0/24").empty() {
+		t.Errorf("resolveTargets(\"10.0.0.0/24\").empty() = true, want false")
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const CSV = "csm2networkreport.csv"
+
+// reportWriter persists a periodic aggregation snapshot. csvReportWriter
+// is the default; newReportWriter picks a SQLite-backed implementation
+// instead when the output path looks like a database file.
+type reportWriter interface {
+	writeSnapshot(timestamp time.Time, rows []snapshotRow) error
+	close() error
+}
+
+// newReportWriter opens the appropriate reportWriter for path, creating
+// the underlying file/table as needed.
+func newReportWriter(path string) (reportWriter, error) {
+	if isSQLitePath(path) {
+		return newSQLiteReportWriter(path)
+	}
+	return newCSVReportWriter(path)
+}
+
+func isSQLitePath(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasSuffix(lower, ".db") || strings.HasSuffix(lower, ".sqlite") || strings.HasSuffix(lower, ".sqlite3")
+}
+
+// csvReportWriter appends one row per tracked IP to a CSV file on every
+// flush, so a single snapshot spans multiple consecutive rows sharing a
+// timestamp.
+type csvReportWriter struct {
+	file   *os.File
+	writer *csv.Writer
+}
+
+func newCSVReportWriter(path string) (*csvReportWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating CSV file: %w", err)
+	}
+
+	w := csv.NewWriter(file)
+	w.Comma = ';'
+
+	headers := []string{"Timestamp", "IP", "RxBytes", "TxBytes", "TotalBytes", "Country", "City", "ASN", "ASOrg"}
+	if err := w.Write(headers); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("writing CSV header: %w", err)
+	}
+	w.Flush()
+
+	return &csvReportWriter{file: file, writer: w}, nil
+}
+
+func (c *csvReportWriter) writeSnapshot(timestamp time.Time, rows []snapshotRow) error {
+	ts := timestamp.Format(time.RFC3339)
+	for _, r := range rows {
+		record := []string{
+			ts,
+			r.IP,
+			strconv.FormatUint(r.RxBytes, 10),
+			strconv.FormatUint(r.TxBytes, 10),
+			strconv.FormatUint(r.Total, 10),
+			r.Country,
+			r.City,
+			strconv.FormatUint(uint64(r.ASN), 10),
+			r.ASOrg,
+		}
+		if err := c.writer.Write(record); err != nil {
+			return fmt.Errorf("writing row: %w", err)
+		}
+	}
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+func (c *csvReportWriter) close() error {
+	c.writer.Flush()
+	return c.file.Close()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteReportWriter is the SQLite-backed alternative to csvReportWriter,
+// used when -out points at a .db/.sqlite/.sqlite3 file. Snapshots are
+// appended to a single usage table, one row per tracked IP per flush.
+type sqliteReportWriter struct {
+	db   *sql.DB
+	stmt *sql.Stmt
+}
+
+func newSQLiteReportWriter(path string) (*sqliteReportWriter, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("opening SQLite database: %w", err)
+	}
+
+	const createTable = `
+CREATE TABLE IF NOT EXISTS usage (
+	timestamp   TEXT NOT NULL,
+	ip          TEXT NOT NULL,
+	rx_bytes    INTEGER NOT NULL,
+	tx_bytes    INTEGER NOT NULL,
+	total_bytes INTEGER NOT NULL,
+	country     TEXT,
+	city        TEXT,
+	asn         INTEGER,
+	as_org      TEXT
+);`
+	if _, err := db.Exec(createTable); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating usage table: %w", err)
+	}
+
+	stmt, err := db.Prepare(`INSERT INTO usage (timestamp, ip, rx_bytes, tx_bytes, total_bytes, country, city, asn, as_org) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("preparing insert statement: %w", err)
+	}
+
+	return &sqliteReportWriter{db: db, stmt: stmt}, nil
+}
+
+func (s *sqliteReportWriter) writeSnapshot(timestamp time.Time, rows []snapshotRow) error {
+	ts := timestamp.Format(time.RFC3339)
+	for _, r := range rows {
+		if _, err := s.stmt.Exec(ts, r.IP, r.RxBytes, r.TxBytes, r.Total, r.Country, r.City, r.ASN, r.ASOrg); err != nil {
+			return fmt.Errorf("inserting row for %s: %w", r.IP, err)
+		}
+	}
+	return nil
+}
+
+func (s *sqliteReportWriter) close() error {
+	s.stmt.Close()
+	return s.db.Close()
+}